@@ -0,0 +1,105 @@
+package webarchive
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// compression identifies the outer framing a WARC/ARC file is stored under.
+// NewReader and Reset sniff this from the first few bytes of the input, the
+// same way they already distinguish WARC from ARC, so callers never have to
+// say which one they have.
+type compression int
+
+const (
+	noCompression compression = iota
+	gzipCompression
+	zstdCompression
+	bzip2Compression
+	xzCompression
+)
+
+var magicBytes = []struct {
+	magic []byte
+	c     compression
+}{
+	{[]byte{0x1f, 0x8b}, gzipCompression},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, zstdCompression},
+	{[]byte{0x42, 0x5a, 0x68}, bzip2Compression},
+	{[]byte{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}, xzCompression},
+}
+
+// sniffCompression peeks at br without consuming any bytes, and reports
+// which compression, if any, the stream is wrapped in.
+func sniffCompression(br *bufio.Reader) (compression, error) {
+	longest := 0
+	for _, m := range magicBytes {
+		if len(m.magic) > longest {
+			longest = len(m.magic)
+		}
+	}
+	peek, err := br.Peek(longest)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return noCompression, err
+	}
+	for _, m := range magicBytes {
+		if len(peek) >= len(m.magic) && string(peek[:len(m.magic)]) == string(m.magic) {
+			return m.c, nil
+		}
+	}
+	return noCompression, nil
+}
+
+// decompressor wraps the per-record framing for each compression this
+// package understands. gzip is handled separately by the existing per-member
+// gzip framing (see gzipwriter.go and OpenAt), since a gzipped WARC's
+// members must be opened one at a time to preserve seekability; the other
+// three are single streams spanning the whole file.
+//
+// This does not support the IIPC zstd shared-dictionary convention (a
+// leading record carrying a dictionary that later records' frames reference
+// by ID): klauspost/compress/zstd only accepts dictionaries at decoder
+// construction time, but the dictionary record itself is only known once
+// decoding is already underway, and this package decodes a zstd WARC as one
+// continuous stream rather than per-record frames the way it does for gzip.
+// A dictionary-compressed WARC therefore fails to decode; fixing that would
+// mean reframing zstd the way gzipwriter.go frames gzip, which nothing in
+// this package currently writes.
+func decompressor(c compression, r io.Reader) (io.Reader, error) {
+	switch c {
+	case gzipCompression:
+		return gzip.NewReader(r)
+	case bzip2Compression:
+		return bzip2.NewReader(r), nil
+	case xzCompression:
+		return xz.NewReader(r)
+	case zstdCompression:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return r, nil
+	}
+}
+
+func (c compression) String() string {
+	switch c {
+	case gzipCompression:
+		return "gzip"
+	case zstdCompression:
+		return "zstd"
+	case bzip2Compression:
+		return "bzip2"
+	case xzCompression:
+		return "xz"
+	default:
+		return "none"
+	}
+}
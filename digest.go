@@ -0,0 +1,30 @@
+package webarchive
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"hash"
+	"io"
+)
+
+// digester wraps a hash.Hash so a payload or block can be hashed as it is
+// copied to the underlying writer, without buffering it in memory.
+type digester struct {
+	h hash.Hash
+	w io.Writer
+}
+
+func newDigester(w io.Writer) *digester {
+	return &digester{h: sha1.New(), w: w}
+}
+
+func (d *digester) Write(p []byte) (int, error) {
+	d.h.Write(p)
+	return d.w.Write(p)
+}
+
+// sum returns the digest in the "sha1:<base32>" form used by the
+// WARC-Block-Digest and WARC-Payload-Digest headers.
+func (d *digester) sum() string {
+	return "sha1:" + base32.StdEncoding.EncodeToString(d.h.Sum(nil))
+}
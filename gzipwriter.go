@@ -0,0 +1,44 @@
+package webarchive
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// gzipMemberWriter wraps an underlying writer so that each call to
+// newMember starts a fresh, independently-decompressible gzip stream. WARC
+// requires records in a .warc.gz file to each be their own gzip member so
+// that a reader (or an index built by Index, see index.go) can seek to a
+// record's compressed offset and decompress just that one record.
+type gzipMemberWriter struct {
+	w io.Writer
+	gz *gzip.Writer
+}
+
+func newGzipMemberWriter(w io.Writer) *gzipMemberWriter {
+	return &gzipMemberWriter{w: w}
+}
+
+// newMember closes the previous gzip member, if any, and opens a new one.
+// Every record written after a call to newMember belongs to that member
+// until the next call to newMember or to close.
+func (g *gzipMemberWriter) newMember() error {
+	if g.gz != nil {
+		if err := g.gz.Close(); err != nil {
+			return err
+		}
+	}
+	g.gz = gzip.NewWriter(g.w)
+	return nil
+}
+
+func (g *gzipMemberWriter) Write(p []byte) (int, error) {
+	return g.gz.Write(p)
+}
+
+func (g *gzipMemberWriter) close() error {
+	if g.gz == nil {
+		return nil
+	}
+	return g.gz.Close()
+}
@@ -0,0 +1,198 @@
+package webarchive
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// IndexOptions controls the format Index writes.
+type IndexOptions struct {
+	// CDXJ selects the CDXJ format (SURT key + timestamp + JSON). The
+	// zero value writes legacy 11-field CDX.
+	CDXJ bool
+}
+
+// cdxLegend is the field legend for the 11-field CDX format Index writes:
+// massaged url, date, original url, mime type, status code, digest,
+// redirect, meta tags, compressed record length, compressed offset, file
+// name.
+const cdxLegend = " CDX N b a m s k r M S V g"
+
+// Index walks every payload-bearing record of r via NextPayload and writes
+// a CDX or CDXJ line for each to w. filename is recorded in the legacy CDX
+// "g" field (and the CDXJ "filename" field) so that an entry is enough, on
+// its own, to find the record again with OpenAt or SeekRecord.
+func Index(r Reader, w io.Writer, filename string, opts IndexOptions) error {
+	if !opts.CDXJ {
+		if _, err := io.WriteString(w, cdxLegend+"\n"); err != nil {
+			return err
+		}
+	}
+	for {
+		rec, err := r.NextPayload()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry, err := indexEntry(rec, filename)
+		if err != nil {
+			return err
+		}
+		if opts.CDXJ {
+			err = writeCDXJLine(w, entry)
+		} else {
+			err = writeCDXLine(w, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type indexRecord struct {
+	surt      string
+	timestamp string
+	original  string
+	mimeType  string
+	status    string
+	digest    string
+	redirect  string
+	offset    int64
+	length    int64
+	filename  string
+}
+
+func indexEntry(rec Record, filename string) (*indexRecord, error) {
+	body, err := ioutil.ReadAll(rec)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(body)
+
+	fields := rec.Fields()
+	status := "-"
+	if v := firstField(fields, "Status"); v != "" {
+		status = v
+	} else if v := firstField(fields, "WARC-Target-URI-Status"); v != "" {
+		status = v
+	}
+
+	mt := firstField(fields, "Content-Type")
+	if mt == "" {
+		mt = sniffMIME(body)
+	} else if parsed, _, perr := mime.ParseMediaType(mt); perr == nil {
+		mt = parsed
+	}
+
+	redirect := "-"
+	if loc := firstField(fields, "Location"); loc != "" {
+		redirect = loc
+	}
+
+	offset, length := int64(-1), int64(-1)
+	if rec.CompressedOffset() >= 0 {
+		offset = rec.CompressedOffset()
+		length = rec.CompressedLength()
+	}
+
+	return &indexRecord{
+		surt:      surt(rec.URL()),
+		timestamp: rec.Date().UTC().Format("20060102150405"),
+		original:  rec.URL(),
+		mimeType:  mt,
+		status:    status,
+		digest:    "sha1:" + base32.StdEncoding.EncodeToString(sum[:]),
+		redirect:  redirect,
+		offset:    offset,
+		length:    length,
+		filename:  filename,
+	}, nil
+}
+
+// sniffMIME falls back to http.DetectContentType when a record carries no
+// Content-Type of its own.
+func sniffMIME(body []byte) string {
+	ct := http.DetectContentType(body)
+	if parsed, _, err := mime.ParseMediaType(ct); err == nil {
+		return parsed
+	}
+	return ct
+}
+
+func writeCDXLine(w io.Writer, e *indexRecord) error {
+	field := func(v int64) string {
+		if v < 0 {
+			return "-"
+		}
+		return strconv.FormatInt(v, 10)
+	}
+	_, err := fmt.Fprintf(w, "%s %s %s %s %s %s %s - %s %s %s\n",
+		e.surt, e.timestamp, e.original, e.mimeType, e.status, e.digest,
+		e.redirect, field(e.length), field(e.offset), e.filename)
+	return err
+}
+
+func writeCDXJLine(w io.Writer, e *indexRecord) error {
+	blob := map[string]interface{}{
+		"url":    e.original,
+		"mime":   e.mimeType,
+		"status": e.status,
+		"digest": e.digest,
+	}
+	if e.redirect != "-" {
+		blob["redirect"] = e.redirect
+	}
+	if e.offset >= 0 {
+		blob["offset"] = e.offset
+		blob["length"] = e.length
+		blob["filename"] = e.filename
+	}
+	j, err := json.Marshal(blob)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s %s %s\n", e.surt, e.timestamp, j)
+	return err
+}
+
+// surt converts a URL into SURT (Sort-friendly URI Reordering Transform)
+// form, e.g. "http://www.example.com/a/b" becomes "com,example,www)/a/b".
+func surt(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+	host := strings.ToLower(u.Hostname())
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	var b strings.Builder
+	b.WriteString(strings.Join(labels, ","))
+	b.WriteByte(')')
+	if u.Port() != "" {
+		b.WriteByte(':')
+		b.WriteString(u.Port())
+	}
+	b.WriteString(path)
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package webarchive
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestResponseWARC(t *testing.T) []byte {
+	t.Helper()
+	resp := &http.Response{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+	}
+	h, block, err := HTTPResponseRecord("http://example.com/hello", resp, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("HTTPResponseRecord: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	rw, err := w.NewRecord(h, int64(len(block)))
+	if err != nil {
+		t.Fatalf("NewRecord: %v", err)
+	}
+	if _, err := rw.Write(block); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestIndexCDXStatusField(t *testing.T) {
+	data := newTestResponseWARC(t)
+	rdr, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Index(rdr, &out, "test.warc", IndexOptions{}); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a legend line and one entry, got %d lines: %q", len(lines), out.String())
+	}
+	// CDX field order, per cdxLegend: surt timestamp original mime status
+	// digest redirect - length offset filename.
+	fields := strings.Fields(lines[1])
+	if status := fields[4]; status != "200" {
+		t.Fatalf("CDX status field = %q, want %q", status, "200")
+	}
+}
+
+func TestIndexCDXJStatusField(t *testing.T) {
+	data := newTestResponseWARC(t)
+	rdr, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Index(rdr, &out, "test.warc", IndexOptions{CDXJ: true}); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	line := strings.TrimSpace(out.String())
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		t.Fatalf("malformed CDXJ line: %q", line)
+	}
+	var blob map[string]interface{}
+	if err := json.Unmarshal([]byte(parts[2]), &blob); err != nil {
+		t.Fatalf("unmarshal CDXJ blob: %v", err)
+	}
+	if status, _ := blob["status"].(string); status != "200" {
+		t.Fatalf("CDXJ status = %q, want %q", status, "200")
+	}
+}
@@ -0,0 +1,459 @@
+package webarchive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is a single WARC or ARC record. Its Fields mirror the WARC/ARC
+// named fields (plus, after NextPayload, any HTTP headers stripped from a
+// response record's block). CompressedOffset and CompressedLength expose
+// the record's position within its enclosing gzip member so that an index
+// entry (see Index) can be used to seek straight back to this one record
+// with OpenAt or Reader.SeekRecord.
+type Record interface {
+	io.Reader
+	URL() string
+	Date() time.Time
+	Size() int64
+	Fields() map[string][]string
+	Slice(start, length int) ([]byte, error)
+	EofSlice(start, length int) ([]byte, error)
+	// CompressedOffset is the byte offset, in the underlying file, of the
+	// gzip member containing this record, or -1 if the record is not
+	// stored within its own gzip member (e.g. a plain WARC/ARC).
+	CompressedOffset() int64
+	// CompressedLength is the length, in bytes, of the gzip member
+	// containing this record, or -1 if it is not applicable.
+	CompressedLength() int64
+}
+
+// record is the one Record implementation shared by Reader.Next,
+// Reader.NextPayload and OpenAt/Reader.SeekRecord. Its whole block is held
+// in memory: WARC/ARC records in practice are read once from front to back
+// by NextPayload anyway, and buffering lets Slice/EofSlice address any
+// byte in the block after the fact, as TestReaders requires.
+type record struct {
+	fields           map[string][]string
+	body             []byte
+	pos              int
+	url              string
+	date             time.Time
+	compressedOffset int64
+	compressedLength int64
+}
+
+func (r *record) Read(p []byte) (int, error) {
+	if r.pos >= len(r.body) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.body[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func (r *record) URL() string                 { return r.url }
+func (r *record) Date() time.Time             { return r.date }
+func (r *record) Size() int64                 { return int64(len(r.body)) }
+func (r *record) Fields() map[string][]string { return r.fields }
+func (r *record) CompressedOffset() int64     { return r.compressedOffset }
+func (r *record) CompressedLength() int64     { return r.compressedLength }
+
+func (r *record) Slice(start, length int) ([]byte, error) {
+	if start < 0 || length < 0 || start+length > len(r.body) {
+		return nil, fmt.Errorf("webarchive: slice [%d:%d] out of range for a %d byte record", start, start+length, len(r.body))
+	}
+	return r.body[start : start+length], nil
+}
+
+func (r *record) EofSlice(start, length int) ([]byte, error) {
+	return r.Slice(len(r.body)-start-length, length)
+}
+
+func firstField(fields map[string][]string, key string) string {
+	if v, ok := fields[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// countingReader tracks how many bytes have been read from the underlying
+// io.Reader, so that Reader.Next can record the compressed offset and
+// length of each gzip member as it is consumed. It implements io.ByteReader
+// so that compress/gzip and compress/flate read through it one byte at a
+// time instead of wrapping it in their own bufio.Reader: without that, their
+// internal read-ahead buffering would pull bytes from the next gzip member
+// before Multistream(false) has a chance to stop at this member's boundary,
+// and n would count past the member it is supposed to measure.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	if br, ok := c.r.(io.ByteReader); ok {
+		b, err := br.ReadByte()
+		if err == nil {
+			c.n++
+		}
+		return b, err
+	}
+	var b [1]byte
+	n, err := c.r.Read(b[:])
+	c.n += int64(n)
+	if n == 0 && err == nil {
+		err = io.ErrNoProgress
+	}
+	return b[0], err
+}
+
+// Reader reads WARC and ARC files, gzipped or plain, as well as the
+// zstd/bzip2/xz-compressed WARC variants handled by compression.go. Build
+// one with NewReader; it is the read-side counterpart to Writer.
+type Reader interface {
+	Next() (Record, error)
+	NextPayload() (Record, error)
+	Reset(io.Reader) error
+	Close() error
+	// SeekRecord jumps directly to the single record at offset, as
+	// recorded in a CDX/CDXJ entry's compressed offset, without replaying
+	// Next() from the start of the file. Readers built over a plain
+	// io.Reader (rather than an io.ReaderAt) return an error; use OpenAt
+	// directly in that case.
+	SeekRecord(offset int64) (Record, error)
+}
+
+// reader is Reader's (only) implementation.
+type reader struct {
+	src  io.Reader // the caller's original source, passed to Reset
+	ra   io.ReaderAt
+	arc  bool
+	comp compression
+
+	cr *countingReader // wraps the raw (still-compressed) source, for gzip member offsets
+	gz *gzip.Reader    // non-nil only when comp == gzipCompression
+	br *bufio.Reader   // reads the logical (decompressed) WARC/ARC text
+
+	memberOffset int64
+	exhausted    bool // true once the last gzip member has been consumed
+
+	continued map[string]*record // open continuations, keyed by WARC-Segment-Origin-ID
+}
+
+// NewReader constructs a Reader over r, sniffing whether it holds a WARC or
+// an ARC file, and whether that file is gzipped (one member per record, as
+// WARC requires for seekability), or wrapped in zstd, bzip2 or xz.
+func NewReader(r io.Reader) (Reader, error) {
+	rdr := &reader{}
+	err := rdr.Reset(r)
+	return rdr, err
+}
+
+// Reset discards any buffered state and starts reading rr from the
+// beginning, reusing the Reader's existing buffers.
+func (r *reader) Reset(rr io.Reader) error {
+	r.src = rr
+	if ra, ok := rr.(io.ReaderAt); ok {
+		r.ra = ra
+	} else {
+		r.ra = nil
+	}
+	r.continued = nil
+	r.memberOffset = 0
+	r.exhausted = false
+
+	peek := bufio.NewReader(rr)
+	comp, err := sniffCompression(peek)
+	if err != nil {
+		return err
+	}
+	r.comp = comp
+
+	var logical io.Reader
+	switch comp {
+	case gzipCompression:
+		r.cr = &countingReader{r: peek}
+		gz, err := gzip.NewReader(r.cr)
+		if err != nil {
+			return err
+		}
+		gz.Multistream(false)
+		r.gz = gz
+		logical = gz
+	default:
+		dr, err := decompressor(comp, peek)
+		if err != nil {
+			return err
+		}
+		logical = dr
+	}
+
+	r.br = bufio.NewReader(logical)
+	first, err := r.br.Peek(5)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	r.arc = !strings.HasPrefix(string(first), "WARC/")
+	return nil
+}
+
+// Close releases resources held by a gzip-framed Reader. It is a nop for a
+// plain, bzip2, xz or zstd Reader.
+func (r *reader) Close() error {
+	if r.gz == nil {
+		return nil
+	}
+	return r.gz.Close()
+}
+
+// Next returns the next record in the file, in order, or io.EOF once the
+// file is exhausted.
+func (r *reader) Next() (Record, error) {
+	if r.arc {
+		return r.nextARC()
+	}
+	return r.nextWARC()
+}
+
+func (r *reader) nextWARC() (Record, error) {
+	if r.exhausted {
+		return nil, io.EOF
+	}
+
+	line, err := r.br.ReadString('\n')
+	if err == io.EOF && strings.TrimSpace(line) == "" {
+		return nil, io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("webarchive: expected a WARC version line, got %q", strings.TrimSpace(line))
+	}
+
+	fields, err := parseHeaderFields(r.br)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseInt(firstField(fields, "Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("webarchive: invalid Content-Length: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.br, body); err != nil {
+		return nil, err
+	}
+	// consume the record's trailing CRLFCRLF separator
+	if _, err := r.br.Discard(4); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	date, _ := time.Parse(time.RFC3339, firstField(fields, "WARC-Date"))
+
+	rec := &record{
+		fields:           fields,
+		body:             body,
+		url:              firstField(fields, "WARC-Target-URI"),
+		date:             date,
+		compressedOffset: -1,
+		compressedLength: -1,
+	}
+	if r.gz != nil {
+		rec.compressedOffset = r.memberOffset
+		if err := r.closeGzipMember(rec); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// closeGzipMember is called once a record has been fully decoded: since
+// each WARC record is its own gzip member, the member should now be
+// exhausted. Peeking a byte drives the gzip.Reader (Multistream(false)) to
+// report that, at which point the member's compressed length is known and
+// the Reader can move on to the next member - recording its start offset
+// for whichever record comes next. A file that packs more than one record
+// per member (non-conforming, but not unheard of) simply leaves
+// CompressedLength at -1 for this record.
+func (r *reader) closeGzipMember(rec *record) error {
+	if _, err := r.br.Peek(1); err != nil && err != io.EOF {
+		return err
+	} else if err == nil {
+		return nil
+	}
+	nextOffset := r.cr.n
+	rec.compressedLength = nextOffset - rec.compressedOffset
+
+	if err := r.gz.Reset(r.cr); err != nil {
+		if err == io.EOF {
+			r.exhausted = true
+			return nil
+		}
+		return err
+	}
+	r.gz.Multistream(false)
+	r.br.Reset(r.gz)
+	r.memberOffset = nextOffset
+	return nil
+}
+
+func (r *reader) nextARC() (Record, error) {
+	if r.exhausted {
+		return nil, io.EOF
+	}
+
+	line, err := r.br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, io.EOF
+	}
+	parts := strings.Fields(line)
+	if len(parts) < 5 {
+		return nil, fmt.Errorf("webarchive: malformed ARC header line: %q", line)
+	}
+	length, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("webarchive: invalid ARC content length: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.br, body); err != nil {
+		return nil, err
+	}
+	r.br.Discard(1) // ARC records are separated by a single newline, not CRLFCRLF
+
+	date, _ := time.Parse("20060102150405", parts[2])
+	fields := map[string][]string{
+		"URL":            {parts[0]},
+		"IP-address":     {parts[1]},
+		"Archive-date":   {parts[2]},
+		"Content-type":   {parts[3]},
+		"Archive-length": {parts[4]},
+	}
+	rec := &record{
+		fields:           fields,
+		body:             body,
+		url:              parts[0],
+		date:             date,
+		compressedOffset: -1,
+		compressedLength: -1,
+	}
+	if r.gz != nil {
+		rec.compressedOffset = r.memberOffset
+		if err := r.closeGzipMember(rec); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// parseHeaderFields reads "Key: value" lines from br until a blank line,
+// as used by both WARC record headers and HTTP message headers.
+func parseHeaderFields(br *bufio.Reader) (map[string][]string, error) {
+	fields := make(map[string][]string)
+	for {
+		l, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		l = strings.TrimRight(l, "\r\n")
+		if l == "" {
+			return fields, nil
+		}
+		i := strings.Index(l, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.TrimSpace(l[:i])
+		val := strings.TrimSpace(l[i+1:])
+		fields[key] = append(fields[key], val)
+	}
+}
+
+// NextPayload returns the next resource, conversion or response record,
+// skipping warcinfo/request/metadata/revisit records, and merges any
+// continuation records into the record they continue. For a response
+// record it also strips the HTTP status line and headers from the block,
+// exposing them, alongside the WARC fields, via Fields().
+func (r *reader) NextPayload() (Record, error) {
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		rc := rec.(*record)
+		typ := firstField(rc.fields, "WARC-Type")
+
+		if typ == "continuation" {
+			origin := firstField(rc.fields, "WARC-Segment-Origin-ID")
+			if prev, ok := r.continued[origin]; ok {
+				prev.body = append(prev.body, rc.body...)
+				if firstField(rc.fields, "WARC-Segment-Total-Length") != "" {
+					delete(r.continued, origin)
+					return prev, nil
+				}
+			}
+			continue
+		}
+
+		switch typ {
+		case "resource", "conversion", "response", "":
+			if typ == "response" || typ == "" {
+				rc = stripHTTPHeaders(rc)
+			}
+			// WARC-Segment-Number:1 with no WARC-Segment-Total-Length marks
+			// the first of a multi-part record; hold onto it until the
+			// continuation(s) that complete it arrive.
+			if firstField(rc.fields, "WARC-Segment-Number") == "1" && firstField(rc.fields, "WARC-Segment-Total-Length") == "" {
+				if r.continued == nil {
+					r.continued = make(map[string]*record)
+				}
+				r.continued[firstField(rc.fields, "WARC-Record-ID")] = rc
+				continue
+			}
+			return rc, nil
+		default:
+			continue
+		}
+	}
+}
+
+// stripHTTPHeaders separates an HTTP status line and headers, if present,
+// from the front of a record's block, leaving just the entity body and
+// folding the HTTP headers into Fields().
+func stripHTTPHeaders(rc *record) *record {
+	br := bufio.NewReader(bytes.NewReader(rc.body))
+	line, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "HTTP/") {
+		return rc
+	}
+	fields, err := parseHeaderFields(br)
+	if err != nil {
+		return rc
+	}
+	for k, v := range fields {
+		rc.fields[k] = v
+	}
+	if parts := strings.Fields(line); len(parts) >= 2 {
+		rc.fields["Status"] = []string{parts[1]}
+	}
+	rest, _ := io.ReadAll(br)
+	rc.body = rest
+	rc.pos = 0
+	return rc
+}
@@ -0,0 +1,236 @@
+package webarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+)
+
+// Recorder wraps an http.RoundTripper so that every request it makes is
+// captured as a matched "request"/"response" record pair in a Writer,
+// turning the module into a capture-side crawler library alongside its
+// existing Reader/Writer for post-hoc work.
+//
+// The recorded response record holds the response exactly as it came off
+// the wire - including a gzipped body, if the server sent one - while
+// WARC-Payload-Digest is computed over the decoded entity, per IIPC
+// guidance that the block digest and the payload digest may legitimately
+// disagree for a compressed transfer.
+type Recorder struct {
+	// MaxBodySize caps how much of a request or response body is
+	// recorded, in bytes. Zero means unlimited. The payload digest is
+	// always computed from the full, untruncated body, so a capped
+	// recording still carries a digest a caller can match against the
+	// real resource.
+	MaxBodySize int64
+	// Dedupe, if true, replaces a response whose payload digest has
+	// already been recorded with a "revisit" record referring back to
+	// the first occurrence, instead of storing the body again.
+	Dedupe bool
+
+	rt   http.RoundTripper
+	w    *Writer
+	seen map[string]string // payload digest -> WARC-Record-ID of first sighting
+}
+
+// NewRecorder returns a Recorder that appends request/response record pairs
+// to w for every round trip made through rt. If rt is nil, http.DefaultTransport
+// is used.
+func NewRecorder(w *Writer, rt http.RoundTripper) *Recorder {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &Recorder{rt: rt, w: w, seen: make(map[string]string)}
+}
+
+// Client returns an *http.Client that records every request it makes
+// through rec.
+func (rec *Recorder) Client() *http.Client {
+	return &http.Client{Transport: rec}
+}
+
+// Get is a convenience wrapper around Client().Get.
+func (rec *Recorder) Get(url string) (*http.Response, error) {
+	return rec.Client().Get(url)
+}
+
+// Do is a convenience wrapper around Client().Do.
+func (rec *Recorder) Do(req *http.Request) (*http.Response, error) {
+	return rec.Client().Do(req)
+}
+
+// RoundTrip implements http.RoundTripper. It preserves Accept-Encoding, if
+// the caller already set one, so that a server's compressed response
+// reaches us - and gets recorded - undecoded; otherwise it asks for gzip
+// explicitly, which has the same effect of stopping Go's transport from
+// transparently (and unrecordably) decoding the body for us. The WARC block
+// always keeps the response exactly as it came off the wire either way; only
+// in the latter case - where Recorder, not the caller, asked for gzip - is
+// the body handed back through Get/Do/RoundTrip decoded, so that a caller
+// who never mentioned Accept-Encoding still sees a normal, uncompressed
+// http.Response, the same as if http.Transport had handled it directly.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	callerWantsGzip := req.Header.Get("Accept-Encoding") != ""
+	if !callerWantsGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	reqID := newRecordID()
+	reqBytes, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rec.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rawBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	respID := newRecordID()
+	decodedBody, isGzip := decodeGzipBody(resp, rawBody)
+	digestBody := rawBody
+	if isGzip {
+		digestBody = decodedBody
+	}
+	digest := payloadDigest(digestBody)
+
+	if rec.MaxBodySize > 0 && int64(len(rawBody)) > rec.MaxBodySize {
+		rawBody = rawBody[:rec.MaxBodySize]
+	}
+	if rec.MaxBodySize > 0 {
+		reqBytes = capBody(reqBytes, rec.MaxBodySize)
+	}
+
+	// Record the response exactly as it came off the wire - headers and
+	// all - before anything below rewrites resp.Header for the caller's
+	// benefit; otherwise the WARC block would still be gzipped while its
+	// own Content-Encoding header said otherwise.
+	if err := rec.writeRequest(req, reqID, respID, reqBytes); err != nil {
+		return resp, err
+	}
+	if err := rec.writeResponse(req, resp, reqID, respID, digest, rawBody); err != nil {
+		return resp, err
+	}
+
+	callerBody := rawBody
+	if !callerWantsGzip && isGzip {
+		callerBody = decodedBody
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(callerBody))
+	return resp, nil
+}
+
+// decodeGzipBody gzip-decodes rawBody if resp declares Content-Encoding:
+// gzip, reporting whether it did so. A body that claims to be gzipped but
+// fails to decode is treated as not gzipped: the caller falls back to
+// rawBody, the same bytes that were actually received.
+func decodeGzipBody(resp *http.Response, rawBody []byte) ([]byte, bool) {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return nil, false
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(rawBody))
+	if err != nil {
+		return nil, false
+	}
+	decoded, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// capBody truncates raw, a dumped HTTP message, to at most max bytes of
+// body, leaving the status line and headers that precede the blank-line
+// separator intact.
+func capBody(raw []byte, max int64) []byte {
+	i := bytes.Index(raw, []byte("\r\n\r\n"))
+	if i < 0 {
+		return raw
+	}
+	head, body := raw[:i+4], raw[i+4:]
+	if int64(len(body)) <= max {
+		return raw
+	}
+	out := make([]byte, 0, len(head)+int(max))
+	out = append(out, head...)
+	out = append(out, body[:max]...)
+	return out
+}
+
+func (rec *Recorder) writeRequest(req *http.Request, reqID, respID string, raw []byte) error {
+	h := Header{}
+	h.Set("WARC-Type", "request")
+	h.Set("WARC-Record-ID", reqID)
+	h.Set("WARC-Target-URI", req.URL.String())
+	h.Set("WARC-Concurrent-To", respID)
+	h.Set("Content-Type", "application/http; msgtype=request")
+	rw, err := rec.w.NewRecord(h, int64(len(raw)))
+	if err != nil {
+		return err
+	}
+	if _, err := rw.Write(raw); err != nil {
+		return err
+	}
+	return rw.Close()
+}
+
+func (rec *Recorder) writeResponse(req *http.Request, resp *http.Response, reqID, respID, digest string, rawBody []byte) error {
+	// Pass a nil body: digest is already known (the decoded entity's, not
+	// the raw block's - see payloadDigest), so there's no need to make
+	// HTTPResponseRecord hash rawBody a second time just to throw the
+	// result away below.
+	h, head, err := HTTPResponseRecord(req.URL.String(), resp, nil)
+	if err != nil {
+		return err
+	}
+	block := append(head, rawBody...)
+	h.Set("WARC-Record-ID", respID)
+	h.Set("WARC-Concurrent-To", reqID)
+	h.Set("WARC-Payload-Digest", digest) // the decoded entity's digest, not the raw block's
+
+	if rec.Dedupe {
+		if origID, ok := rec.seen[digest]; ok {
+			h.Set("WARC-Type", "revisit")
+			h.Set("WARC-Refers-To", origID)
+			h.Set("WARC-Profile", "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest")
+			rw, err := rec.w.NewRecord(h, 0)
+			if err != nil {
+				return err
+			}
+			return rw.Close()
+		}
+		rec.seen[digest] = respID
+	}
+
+	rw, err := rec.w.NewRecord(h, int64(len(block)))
+	if err != nil {
+		return err
+	}
+	if _, err := rw.Write(block); err != nil {
+		return err
+	}
+	return rw.Close()
+}
+
+// payloadDigest hashes body, the decoded entity: WARC-Payload-Digest must
+// match the content a browser would see, even though the recorded block
+// keeps the bytes as sent, and must be computed before MaxBodySize
+// truncates anything.
+func payloadDigest(body []byte) string {
+	var buf bytes.Buffer
+	dig := newDigester(&buf)
+	dig.Write(body)
+	return dig.sum()
+}
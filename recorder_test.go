@@ -0,0 +1,133 @@
+package webarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRecorderDecodesGzipForCaller checks that a caller going through
+// Recorder.Get sees a normal, decoded response - even though Recorder asked
+// for gzip itself to keep the server from being auto-decoded away - while
+// the WARC response record still holds the original compressed bytes.
+func TestRecorderDecodesGzipForCaller(t *testing.T) {
+	const payload = "hello decoded world"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(payload))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rec := NewRecorder(NewWriter(&buf), nil)
+
+	resp, err := rec.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(body) != payload {
+		t.Fatalf("caller body = %q, want %q", body, payload)
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+		t.Fatalf("Content-Encoding leaked through to caller: %q", ce)
+	}
+
+	rdr, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var sawResponse bool
+	for {
+		r, err := rdr.Next()
+		if err != nil {
+			break
+		}
+		if firstField(r.Fields(), "WARC-Type") != "response" {
+			continue
+		}
+		sawResponse = true
+		block, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll record: %v", err)
+		}
+		i := bytes.Index(block, []byte("\r\n\r\n"))
+		if i < 0 {
+			t.Fatalf("no header/body separator in response block")
+		}
+		head := string(block[:i])
+		if !strings.Contains(head, "Content-Encoding: gzip") {
+			t.Fatalf("recorded response headers lost Content-Encoding: gzip even though the block is still gzipped:\n%s", head)
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(block[i+4:]))
+		if err != nil {
+			t.Fatalf("recorded response block wasn't still gzipped: %v", err)
+		}
+		decoded, err := ioutil.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("ReadAll gzip: %v", err)
+		}
+		if string(decoded) != payload {
+			t.Fatalf("recorded payload = %q, want %q", decoded, payload)
+		}
+	}
+	if !sawResponse {
+		t.Fatalf("no response record found")
+	}
+}
+
+// TestRecorderWritesMatchedRequestResponsePair checks that a round trip
+// produces a request/response record pair linked by WARC-Concurrent-To,
+// with a real payload digest on the response.
+func TestRecorderWritesMatchedRequestResponsePair(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain body"))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rec := NewRecorder(NewWriter(&buf), nil)
+	resp, err := rec.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	rdr, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var reqRec, respRec Record
+	for {
+		r, err := rdr.Next()
+		if err != nil {
+			break
+		}
+		switch firstField(r.Fields(), "WARC-Type") {
+		case "request":
+			reqRec = r
+		case "response":
+			respRec = r
+		}
+	}
+	if reqRec == nil || respRec == nil {
+		t.Fatalf("expected both a request and a response record, got req=%v resp=%v", reqRec, respRec)
+	}
+	if got, want := firstField(respRec.Fields(), "WARC-Concurrent-To"), firstField(reqRec.Fields(), "WARC-Record-ID"); got != want {
+		t.Fatalf("WARC-Concurrent-To = %q, want the request's WARC-Record-ID %q", got, want)
+	}
+	if digest := firstField(respRec.Fields(), "WARC-Payload-Digest"); !strings.HasPrefix(digest, "sha1:") {
+		t.Fatalf("WARC-Payload-Digest = %q", digest)
+	}
+}
@@ -0,0 +1,119 @@
+package webarchive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// raReader adapts an io.ReaderAt, read sequentially from a fixed starting
+// offset, into an io.Reader - with no length limit, unlike io.SectionReader
+// with a fixed size. Read follows io.ReaderAt's own contract for where the
+// underlying data ends (a short read accompanied by io.EOF).
+type raReader struct {
+	ra  io.ReaderAt
+	off int64
+}
+
+func (r *raReader) Read(p []byte) (int, error) {
+	n, err := r.ra.ReadAt(p, r.off)
+	r.off += int64(n)
+	return n, err
+}
+
+// OpenAt reads exactly one WARC record starting at offset in ra. offset is
+// expected to be the CompressedOffset recorded against a CDX/CDXJ entry (see
+// Index): for a gzipped WARC that is the start of the record's own gzip
+// member, so OpenAt opens a fresh gzip.Reader there rather than scanning
+// from the beginning of the file. For a plain WARC, offset is simply the
+// byte offset of the "WARC/1.x" line.
+func OpenAt(ra io.ReaderAt, offset int64) (Record, error) {
+	cr := &countingReader{r: bufio.NewReader(&raReader{ra: ra, off: offset})}
+
+	var rdr io.Reader = cr
+	var magic [2]byte
+	n, err := io.ReadFull(cr, magic[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	gzipped := n == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+	cr.r = bufio.NewReader(&raReader{ra: ra, off: offset}) // rewind: re-read the magic bytes as part of the stream
+	cr.n = 0
+
+	var compressedLength int64 = -1
+	if gzipped {
+		gz, err := gzip.NewReader(cr)
+		if err != nil {
+			return nil, err
+		}
+		gz.Multistream(false) // stop at this record's member boundary
+		defer gz.Close()
+		rdr = gz
+	}
+
+	rec, err := decodeOneRecord(bufio.NewReader(rdr), offset)
+	if err != nil {
+		return nil, err
+	}
+	if gzipped {
+		// Drain the rest of this gzip member (the trailing CRLFCRLF
+		// should be all that's left) so cr.n lands on the member's true
+		// compressed length.
+		io.Copy(io.Discard, rdr)
+		compressedLength = cr.n
+	}
+	rec.compressedLength = compressedLength
+	return rec, nil
+}
+
+// SeekRecord jumps directly to a single record at offset within r's
+// underlying data, without replaying Next() from the start of the file.
+// It requires r to have been built, via NewReader or Reset, over a source
+// that also implements io.ReaderAt (as both siegreader buffers and
+// bytes.Reader do); otherwise it returns an error.
+func (r *reader) SeekRecord(offset int64) (Record, error) {
+	if r.ra == nil {
+		return nil, fmt.Errorf("webarchive: SeekRecord requires a Reader built over an io.ReaderAt")
+	}
+	return OpenAt(r.ra, offset)
+}
+
+// decodeOneRecord parses a single WARC record's header block and body from
+// br, reusing the same header-field parser Reader.Next uses.
+func decodeOneRecord(br *bufio.Reader, compressedOffset int64) (*record, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("webarchive: not a WARC record: %q", strings.TrimSpace(line))
+	}
+
+	fields, err := parseHeaderFields(br)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseInt(firstField(fields, "Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("webarchive: invalid Content-Length: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, err
+	}
+
+	date, _ := time.Parse(time.RFC3339, firstField(fields, "WARC-Date"))
+	return &record{
+		fields:           fields,
+		body:             body,
+		url:              firstField(fields, "WARC-Target-URI"),
+		date:             date,
+		compressedOffset: compressedOffset,
+		compressedLength: -1,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package webarchive
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+func newTestGzipWARC(t *testing.T, bodies []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewGzipWriter(&buf)
+	for i, body := range bodies {
+		h := Header{}
+		h.Set("WARC-Type", "resource")
+		h.Set("WARC-Target-URI", fmt.Sprintf("http://example.com/%d", i))
+		rw, err := w.NewRecord(h, int64(len(body)))
+		if err != nil {
+			t.Fatalf("NewRecord %d: %v", i, err)
+		}
+		if _, err := rw.Write([]byte(body)); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+		if err := rw.Close(); err != nil {
+			t.Fatalf("Close %d: %v", i, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestSeekRecordMatchesNext checks that OpenAt and Reader.SeekRecord, given
+// the CompressedOffset of each record as reported during sequential Next()
+// iteration, return exactly the same bytes as that sequential iteration -
+// without replaying the file from the start.
+func TestSeekRecordMatchesNext(t *testing.T) {
+	bodies := []string{"first record", "second record", "third record"}
+	data := newTestGzipWARC(t, bodies)
+
+	rdr, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var offsets []int64
+	var want [][]byte
+	for i := range bodies {
+		rec, err := rdr.Next()
+		if err != nil {
+			t.Fatalf("Next %d: %v", i, err)
+		}
+		if off := rec.CompressedOffset(); off < 0 {
+			t.Fatalf("record %d: CompressedOffset = %d, want >= 0", i, off)
+		}
+		offsets = append(offsets, rec.CompressedOffset())
+		b, err := ioutil.ReadAll(rec)
+		if err != nil {
+			t.Fatalf("ReadAll %d: %v", i, err)
+		}
+		want = append(want, b)
+	}
+
+	ra := bytes.NewReader(data)
+	for i, off := range offsets {
+		rec, err := OpenAt(ra, off)
+		if err != nil {
+			t.Fatalf("OpenAt %d: %v", i, err)
+		}
+		got, err := ioutil.ReadAll(rec)
+		if err != nil {
+			t.Fatalf("ReadAll %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want[i]) {
+			t.Fatalf("record %d: OpenAt = %q, want %q", i, got, want[i])
+		}
+	}
+
+	rdr2, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	for i, off := range offsets {
+		rec, err := rdr2.SeekRecord(off)
+		if err != nil {
+			t.Fatalf("SeekRecord %d: %v", i, err)
+		}
+		got, err := ioutil.ReadAll(rec)
+		if err != nil {
+			t.Fatalf("ReadAll %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want[i]) {
+			t.Fatalf("record %d: SeekRecord = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestSeekRecordRequiresReaderAt(t *testing.T) {
+	data := newTestGzipWARC(t, []string{"only record"})
+	// bytes.NewBuffer's *bytes.Buffer does not implement io.ReaderAt.
+	rdr, err := NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if _, err := rdr.SeekRecord(0); err == nil {
+		t.Fatalf("expected an error seeking over a non-io.ReaderAt source")
+	}
+}
@@ -0,0 +1,18 @@
+package webarchive
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// newRecordID returns a fresh WARC-Record-ID in the "<urn:uuid:...>" form
+// mandated by the WARC 1.1 spec (a version 4, variant 1 UUID per RFC 4122).
+func newRecordID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err) // crypto/rand failing is not something we can recover from
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,191 @@
+package webarchive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Header holds the named fields of a WARC record, keyed case-sensitively as
+// they appear on the wire (e.g. "WARC-Type", "WARC-Target-URI"). It mirrors
+// the shape of the map returned by a Record's Fields() method so that values
+// read from one WARC can be forwarded, largely unchanged, into another.
+type Header map[string][]string
+
+// Set replaces any existing values for key with a single value.
+func (h Header) Set(key, value string) { h[key] = []string{value} }
+
+// Add appends value to any existing values for key.
+func (h Header) Add(key, value string) { h[key] = append(h[key], value) }
+
+// Get returns the first value associated with key, or "" if there is none.
+func (h Header) Get(key string) string {
+	v := h[key]
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+// Writer appends WARC records to an underlying io.Writer. Create one with
+// NewWriter or NewGzipWriter; it is the write-side counterpart to Reader.
+type Writer struct {
+	w   io.Writer
+	gzw *gzipMemberWriter
+}
+
+// NewWriter returns a Writer that appends plain (uncompressed) WARC records
+// to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// NewGzipWriter returns a Writer that gzips each record as its own member,
+// as required for a seekable .warc.gz (see CompressedOffset/CompressedLength
+// in index.go).
+func NewGzipWriter(w io.Writer) *Writer {
+	gzw := newGzipMemberWriter(w)
+	return &Writer{w: w, gzw: gzw}
+}
+
+// recordWriter buffers a record's block so that, once the caller has
+// written the whole thing and calls Close, its WARC-Block-Digest can be
+// computed and folded into the header - which, per WARC framing, has to be
+// written before the block it describes, not after.
+type recordWriter struct {
+	wr     *Writer
+	header Header
+	want   int64
+	dig    *digester
+}
+
+func (rw *recordWriter) Write(p []byte) (int, error) {
+	return rw.dig.Write(p)
+}
+
+func (rw *recordWriter) Close() error {
+	buf := rw.dig.w.(*bytes.Buffer)
+	if int64(buf.Len()) != rw.want {
+		return fmt.Errorf("webarchive: wrote %d bytes to record, header declared Content-Length %d", buf.Len(), rw.want)
+	}
+	rw.header.Set("WARC-Block-Digest", rw.dig.sum())
+
+	var dest io.Writer = rw.wr.w
+	if rw.wr.gzw != nil {
+		if err := rw.wr.gzw.newMember(); err != nil {
+			return err
+		}
+		dest = rw.wr.gzw
+	}
+	if err := writeHeaderBlock(dest, rw.header); err != nil {
+		return err
+	}
+	if _, err := dest.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := dest.Write(crlf2)
+	return err
+}
+
+var crlf2 = []byte("\r\n\r\n")
+
+// NewRecord returns a writer that the caller must write exactly size bytes
+// of record payload to, then Close. Close is what actually emits the
+// WARC/1.1 record: it fills in WARC-Record-ID and WARC-Date if header
+// doesn't already carry them, Content-Length from size, and
+// WARC-Block-Digest computed over the payload just written, then writes
+// the header block followed by the payload - in that order, since the
+// digest has to be known before the header naming it can go out. If header
+// already carries WARC-Payload-Digest (computed ahead of time, as
+// HTTPResponseRecord does, since the payload is the decoded HTTP entity
+// rather than the whole block) it is written through unchanged.
+func (wr *Writer) NewRecord(header Header, size int64) (io.WriteCloser, error) {
+	if header.Get("WARC-Record-ID") == "" {
+		header.Set("WARC-Record-ID", newRecordID())
+	}
+	if header.Get("WARC-Date") == "" {
+		header.Set("WARC-Date", time.Now().UTC().Format(time.RFC3339Nano))
+	}
+	header.Set("Content-Length", fmt.Sprintf("%d", size))
+	if header.Get("WARC-Type") == "" {
+		header.Set("WARC-Type", "resource")
+	}
+
+	var buf bytes.Buffer
+	return &recordWriter{wr: wr, header: header, want: size, dig: newDigester(&buf)}, nil
+}
+
+func writeHeaderBlock(w io.Writer, header Header) error {
+	if _, err := io.WriteString(w, "WARC/1.1\r\n"); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		if k == "WARC-Record-ID" || k == "WARC-Type" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	write := func(key string) error {
+		for _, v := range header[key] {
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := write("WARC-Type"); err != nil {
+		return err
+	}
+	if err := write("WARC-Record-ID"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := write(k); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// Close flushes any open gzip member. It is a nop for a plain Writer.
+func (wr *Writer) Close() error {
+	if wr.gzw == nil {
+		return nil
+	}
+	return wr.gzw.close()
+}
+
+// HTTPResponseRecord assembles the block for a WARC "response" record from
+// an *http.Response: the HTTP status line and headers are serialised ahead
+// of the body, as WARC's "application/http" block format requires. It
+// returns a ready-to-use Header alongside the full block, so the caller can
+// pass len(block) as the size argument to NewRecord and copy block to the
+// writer it returns.
+func HTTPResponseRecord(targetURI string, resp *http.Response, body io.Reader) (Header, []byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	resp.Header.Write(&buf)
+	buf.WriteString("\r\n")
+
+	var payload bytes.Buffer
+	dig := newDigester(&payload)
+	if body != nil {
+		if _, err := io.Copy(dig, body); err != nil {
+			return nil, nil, err
+		}
+	}
+	buf.Write(payload.Bytes())
+
+	h := Header{}
+	h.Set("WARC-Type", "response")
+	h.Set("WARC-Target-URI", targetURI)
+	h.Set("Content-Type", "application/http; msgtype=response")
+	h.Set("WARC-Payload-Digest", dig.sum())
+	return h, buf.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package webarchive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriterRecordRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	h := Header{}
+	h.Set("WARC-Type", "resource")
+	h.Set("WARC-Target-URI", "http://example.com/hello")
+	body := []byte("hello world")
+	rw, err := w.NewRecord(h, int64(len(body)))
+	if err != nil {
+		t.Fatalf("NewRecord: %v", err)
+	}
+	if _, err := rw.Write(body); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rdr, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	rec, err := rdr.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if rec.URL() != "http://example.com/hello" {
+		t.Fatalf("URL = %q", rec.URL())
+	}
+	got, err := ioutil.ReadAll(rec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Fatalf("body = %q, want %q", got, body)
+	}
+	if digest := firstField(rec.Fields(), "WARC-Block-Digest"); !strings.HasPrefix(digest, "sha1:") {
+		t.Fatalf("WARC-Block-Digest = %q", digest)
+	}
+	if _, err := rdr.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the only record, got %v", err)
+	}
+}
+
+func TestHTTPResponseRecordPayloadDigest(t *testing.T) {
+	resp := &http.Response{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+	}
+	h, block, err := HTTPResponseRecord("http://example.com/hello", resp, strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("HTTPResponseRecord: %v", err)
+	}
+	if !strings.HasPrefix(h.Get("WARC-Payload-Digest"), "sha1:") {
+		t.Fatalf("WARC-Payload-Digest = %q", h.Get("WARC-Payload-Digest"))
+	}
+	h.Set("WARC-Target-URI", "http://example.com/hello")
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	rw, err := w.NewRecord(h, int64(len(block)))
+	if err != nil {
+		t.Fatalf("NewRecord: %v", err)
+	}
+	if _, err := rw.Write(block); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rdr, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	rec, err := rdr.NextPayload()
+	if err != nil {
+		t.Fatalf("NextPayload: %v", err)
+	}
+	got, err := ioutil.ReadAll(rec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Fatalf("body = %q", got)
+	}
+	if ct := firstField(rec.Fields(), "Content-Type"); ct != "text/plain" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+}